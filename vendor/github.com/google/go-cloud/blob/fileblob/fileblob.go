@@ -21,18 +21,42 @@
 // or the sequence "/./" is not permitted. This is to ensure that blob names map
 // cleanly onto files underneath a directory.
 //
-// It does not support any types for As.
+// SignedURL is supported when OpenBucketWithOptions is given a SigningKey
+// and a BaseURL; the companion Handler serves the URLs it produces.
+//
+// A *blob.Bucket opened by OpenBucket can be passed anywhere an io/fs.FS
+// is expected (http.FS, fs.WalkDir, ...). Names that io/fs.ValidPath
+// accepts but resolvePath rejects (e.g. containing characters outside
+// fileblob's restricted charset) are not mapped to fs.ErrInvalid: they
+// surface through fs.FS as an *fs.PathError wrapping fileblob's own error.
+//
+// fileblob implements driver.ResumableBucket, so (*blob.Bucket).ResumeWrite
+// can be used to continue an upload interrupted by a crash; see
+// resumable.go for the on-disk layout under .uploads.
+//
+// As supports *os.File for (*blob.Reader).As and (*blob.Writer).As, giving
+// access to the open file backing a read or (possibly still-temporary)
+// write; BeforeRead and BeforeWrite callbacks receive the same *os.File.
+// (*blob.Bucket).ErrorAs supports *os.PathError.
 package fileblob
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"io/fs"
+	"net/http"
+	"net/url"
 	"os"
 	slashpath "path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/go-cloud/blob"
 	"github.com/google/go-cloud/blob/driver"
@@ -41,12 +65,45 @@ import (
 const defaultPageSize = 1000
 
 type bucket struct {
-	dir string
+	dir  string
+	opts Options
+}
+
+// Options sets options for OpenBucketWithOptions.
+type Options struct {
+	// SigningKey, together with BaseURL, enables (*blob.Bucket).SignedURL.
+	// URLs are signed with HMAC-SHA256 over the key, HTTP method, and
+	// expiry, so keep this secret and stable across restarts of the
+	// process that calls OpenBucketWithOptions and the process running
+	// Handler.
+	SigningKey []byte
+	// BaseURL is the URL prefix that Handler is mounted at. SignedURL
+	// returns URLs of the form "<BaseURL>/<key>?...". Required if
+	// SigningKey is set.
+	BaseURL string
+	// DefaultSignedURLExpiry is used as the SignedURL TTL when the
+	// caller's driver.SignedURLOptions.Expiry is the zero value.
+	// Defaults to 1 hour.
+	DefaultSignedURLExpiry time.Duration
+	// NoAtomicWrites disables the default temp-file-plus-rename publish
+	// in NewTypedWriter, reverting to writing directly into the
+	// destination path. Set this only if you need the old behavior (e.g.
+	// your filesystem doesn't support rename, or you're relying on
+	// observing partial writes); it gives up the guarantee that readers
+	// and ListPaged never see a half-written blob.
+	NoAtomicWrites bool
 }
 
 // OpenBucket creates a *blob.Bucket that reads and writes to dir.
 // dir must exist.
 func OpenBucket(dir string) (*blob.Bucket, error) {
+	return OpenBucketWithOptions(dir, nil)
+}
+
+// OpenBucketWithOptions is like OpenBucket, but allows configuring
+// optional behavior such as signed URLs via opts. A nil opts is
+// equivalent to the zero value.
+func OpenBucketWithOptions(dir string, opts *Options) (*blob.Bucket, error) {
 	info, err := os.Stat(dir)
 	if err != nil {
 		return nil, fmt.Errorf("open file bucket: %v", err)
@@ -54,7 +111,10 @@ func OpenBucket(dir string) (*blob.Bucket, error) {
 	if !info.IsDir() {
 		return nil, fmt.Errorf("open file bucket: %s is not a directory", dir)
 	}
-	return blob.NewBucket(&bucket{dir}), nil
+	if opts == nil {
+		opts = &Options{}
+	}
+	return blob.NewBucket(&bucket{dir: dir, opts: *opts}), nil
 }
 
 // resolvePath converts a key into a relative filesystem path. It guarantees
@@ -93,7 +153,7 @@ func (b *bucket) forKey(key string) (string, os.FileInfo, *xattrs, error) {
 	info, err := os.Stat(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return "", nil, nil, fileError{relpath: relpath, msg: err.Error(), kind: driver.NotFound}
+			return "", nil, nil, fileError{relpath: relpath, msg: err.Error(), kind: driver.NotFound, wrapped: err}
 		}
 		return "", nil, nil, fmt.Errorf("open file blob %s: %v", key, err)
 	}
@@ -104,51 +164,198 @@ func (b *bucket) forKey(key string) (string, os.FileInfo, *xattrs, error) {
 	return path, info, &xa, nil
 }
 
-// ListPaged implements driver.ListPaged.
+// walkedFile is a candidate object collected by the initial ListPaged walk,
+// before sorting and paging.
+type walkedFile struct {
+	key     string
+	modTime time.Time
+	size    int64
+}
+
+// ListPaged implements driver.ListPaged. It walks b.dir recursively,
+// translating OS paths back into slash-separated keys so that it matches
+// the namespace NewRangeReader, NewTypedWriter, etc. expect. filepath.WalkDir
+// yields entries in directory-recursion order, not key order (e.g. a/foo
+// comes before a.txt), so every candidate is collected first and then
+// sorted lexicographically by key before paging or delimiter-collapsing,
+// matching the sorted-order contract driver.ListPaged documents and that
+// the S3, GCS, and Azure drivers provide natively.
 func (b *bucket) ListPaged(ctx context.Context, opts *driver.ListOptions) (*driver.ListPage, error) {
-	// List everything in the directory, sorted by name.
-	// TODO(Issue #541): This should be doing a recursive walk of the directory
-	// as well as translating into the abstract namespace that we've created.
-	fileinfos, err := ioutil.ReadDir(b.dir)
-	if err != nil {
-		return nil, err
-	}
 	pageSize := opts.PageSize
 	if pageSize == 0 {
 		pageSize = defaultPageSize
 	}
-	var result driver.ListPage
-	for _, info := range fileinfos {
-		// Skip the self-generated attribute files.
-		if strings.HasSuffix(info.Name(), attrsExt) {
-			continue
+
+	var files []walkedFile
+	err := filepath.WalkDir(b.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == b.dir {
+			return nil
+		}
+		relpath, err := filepath.Rel(b.dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relpath)
+		if d.IsDir() {
+			// In-progress resumable uploads live under uploadsDirName, at
+			// the bucket root, and are never part of the bucket's
+			// namespace. A same-named directory elsewhere in the tree is
+			// a legitimate key prefix and must still be walked.
+			if relpath == uploadsDirName {
+				return filepath.SkipDir
+			}
+			// Recurse into it; empty directories simply produce no
+			// objects and are never surfaced themselves.
+			return nil
+		}
+		// Skip the self-generated attribute files and in-flight temp
+		// files from a NewTypedWriter that hasn't been renamed into
+		// place yet.
+		if strings.HasSuffix(key, attrsExt) || isTempFile(key) {
+			return nil
 		}
 		// Skip files that don't match the Prefix.
-		if opts.Prefix != "" && !strings.HasPrefix(info.Name(), opts.Prefix) {
-			continue
+		if opts.Prefix != "" && !strings.HasPrefix(key, opts.Prefix) {
+			return nil
 		}
-		// If a PageToken was provided, skip to it.
-		if len(opts.PageToken) > 0 && info.Name() < string(opts.PageToken) {
-			continue
+		info, err := d.Info()
+		if err != nil {
+			return err
 		}
-		// If we've got a full page of results, and there are more
-		// to come, set NextPageToken and stop here.
+		files = append(files, walkedFile{key: key, modTime: info.ModTime(), size: info.Size()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].key < files[j].key })
+
+	var result driver.ListPage
+	// lastDirPrefix tracks the "subdir/" we most recently emitted, so that
+	// we can collapse further keys under it instead of emitting them too.
+	var lastDirPrefix string
+	token := string(opts.PageToken)
+	for _, wf := range files {
+		key := wf.key
+		// If a PageToken was provided, skip everything it already covers:
+		// either a plain key we've already returned, or (if it names a
+		// collapsed directory) anything underneath that directory.
+		if token != "" {
+			if key <= token || (strings.HasSuffix(token, opts.Delimiter) && opts.Delimiter != "" && strings.HasPrefix(key, token)) {
+				continue
+			}
+		}
+
+		if opts.Delimiter != "" {
+			// If key, stripped of the Prefix, contains Delimiter, collapse
+			// everything through the delimiter into a single "directory"
+			// result, keyed by the prefix up to and including it.
+			afterPrefix := key[len(opts.Prefix):]
+			if idx := strings.Index(afterPrefix, opts.Delimiter); idx != -1 {
+				dirPrefix := opts.Prefix + afterPrefix[:idx+len(opts.Delimiter)]
+				if dirPrefix == lastDirPrefix {
+					// Already emitted this directory; skip the rest of
+					// its contents.
+					continue
+				}
+				if len(result.Objects) == pageSize {
+					// NextPageToken must name the last *emitted* key, not
+					// dirPrefix itself: the resume filter above treats the
+					// token as already covered (key <= token, or a prefix
+					// match for a collapsed directory), so a token of
+					// dirPrefix would skip the whole not-yet-emitted
+					// directory on the next call.
+					result.NextPageToken = []byte(result.Objects[len(result.Objects)-1].Key)
+					return &result, nil
+				}
+				lastDirPrefix = dirPrefix
+				result.Objects = append(result.Objects, &driver.ListObject{
+					Key:   dirPrefix,
+					IsDir: true,
+				})
+				continue
+			}
+		}
+
+		// If we've got a full page of results, and there are more to
+		// come, set NextPageToken and stop here. As above, the token must
+		// be the last emitted key so the resume filter's key <= token
+		// skip doesn't also drop key itself.
 		if len(result.Objects) == pageSize {
-			result.NextPageToken = []byte(info.Name())
-			break
+			result.NextPageToken = []byte(result.Objects[len(result.Objects)-1].Key)
+			return &result, nil
 		}
-		// Add this object.
 		result.Objects = append(result.Objects, &driver.ListObject{
-			Key:     info.Name(),
-			ModTime: info.ModTime(),
-			Size:    info.Size(),
+			Key:     key,
+			ModTime: wf.modTime,
+			Size:    wf.size,
 		})
 	}
 	return &result, nil
 }
 
-// As implements driver.As.
-func (b *bucket) As(i interface{}) bool { return false }
+// tempSuffixLen is the length of the hex-encoded random suffix newUploadID
+// generates, used below to recognize fileblob's own in-flight
+// ".tmp-<suffix>" files without false-matching a legitimate key that
+// happens to contain ".tmp-", such as "backup.tmp-1".
+const tempSuffixLen = 32
+
+// isTempFile reports whether key is one of fileblob's own in-flight
+// "<path>.tmp-<suffix>" files created by NewTypedWriter, identified by the
+// exact length and alphabet of the hex suffix newUploadID produces. Their
+// "<path>.tmp-<suffix>" + attrsExt companions end in attrsExt and are
+// recognized by that instead.
+func isTempFile(key string) bool {
+	const marker = ".tmp-"
+	i := strings.LastIndex(key, marker)
+	if i == -1 {
+		return false
+	}
+	suffix := key[i+len(marker):]
+	if len(suffix) != tempSuffixLen {
+		return false
+	}
+	for _, c := range suffix {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// As implements driver.As. It supports *bucket, letting code that knows
+// it's talking to fileblob reach the driver's own directory path.
+func (b *bucket) As(i interface{}) bool {
+	p, ok := i.(**bucket)
+	if !ok {
+		return false
+	}
+	*p = b
+	return true
+}
+
+// ErrorAs implements driver.ErrorAs. It supports *os.PathError, which is
+// available for the not-found errors fileblob builds from a failed
+// os.Stat or os.Remove; its other errors support no types.
+func (b *bucket) ErrorAs(err error, i interface{}) bool {
+	fe, ok := err.(fileError)
+	if !ok || fe.wrapped == nil {
+		return false
+	}
+	pe, ok := fe.wrapped.(*os.PathError)
+	if !ok {
+		return false
+	}
+	p, ok := i.(**os.PathError)
+	if !ok {
+		return false
+	}
+	*p = pe
+	return true
+}
 
 // Attributes implements driver.Attributes.
 func (b *bucket) Attributes(ctx context.Context, key string) (driver.Attributes, error) {
@@ -165,7 +372,7 @@ func (b *bucket) Attributes(ctx context.Context, key string) (driver.Attributes,
 }
 
 // NewRangeReader implements driver.NewRangeReader.
-func (b *bucket) NewRangeReader(ctx context.Context, key string, offset, length int64) (driver.Reader, error) {
+func (b *bucket) NewRangeReader(ctx context.Context, key string, offset, length int64, opts *driver.ReaderOptions) (driver.Reader, error) {
 	path, info, xa, err := b.forKey(key)
 	if err != nil {
 		return nil, err
@@ -174,8 +381,15 @@ func (b *bucket) NewRangeReader(ctx context.Context, key string, offset, length
 	if err != nil {
 		return nil, fmt.Errorf("open file blob %s: %v", key, err)
 	}
+	if opts != nil && opts.BeforeRead != nil {
+		if err := opts.BeforeRead(fileAsFunc(f)); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
 	if offset > 0 {
 		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
 			return nil, fmt.Errorf("open file blob %s: %v", key, err)
 		}
 	}
@@ -185,7 +399,7 @@ func (b *bucket) NewRangeReader(ctx context.Context, key string, offset, length
 	}
 	return reader{
 		r: r,
-		c: f,
+		f: f,
 		attrs: driver.ReaderAttributes{
 			ContentType: xa.ContentType,
 			ModTime:     info.ModTime(),
@@ -196,7 +410,7 @@ func (b *bucket) NewRangeReader(ctx context.Context, key string, offset, length
 
 type reader struct {
 	r     io.Reader
-	c     io.Closer
+	f     *os.File
 	attrs driver.ReaderAttributes
 }
 
@@ -208,19 +422,31 @@ func (r reader) Read(p []byte) (int, error) {
 }
 
 func (r reader) Close() error {
-	if r.c == nil {
+	if r.f == nil {
 		return nil
 	}
-	return r.c.Close()
+	return r.f.Close()
 }
 
 func (r reader) Attributes() driver.ReaderAttributes {
 	return r.attrs
 }
 
-func (r reader) As(i interface{}) bool { return false }
+// As implements driver.Reader.As. It supports *os.File, giving access to
+// the open file backing the reader (e.g. for syscall.Flock or sendfile).
+func (r reader) As(i interface{}) bool {
+	p, ok := i.(**os.File)
+	if !ok || r.f == nil {
+		return false
+	}
+	*p = r.f
+	return true
+}
 
-// NewTypedWriter implements driver.NewTypedWriter.
+// NewTypedWriter implements driver.NewTypedWriter. Unless
+// Options.NoAtomicWrites is set, it writes to temp files alongside path
+// and publishes them with os.Rename on Close, so concurrent readers and
+// ListPaged never observe a half-written blob or stale attrs.
 func (b *bucket) NewTypedWriter(ctx context.Context, key string, contentType string, opts *driver.WriterOptions) (driver.Writer, error) {
 	relpath, err := resolvePath(key)
 	if err != nil {
@@ -233,15 +459,7 @@ func (b *bucket) NewTypedWriter(ctx context.Context, key string, contentType str
 	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
 		return nil, fmt.Errorf("open file blob %s: %v", key, err)
 	}
-	f, err := os.Create(path)
-	if err != nil {
-		return nil, fmt.Errorf("open file blob %s: %v", key, err)
-	}
-	if opts.BeforeWrite != nil {
-		if err := opts.BeforeWrite(func(interface{}) bool { return false }); err != nil {
-			return nil, err
-		}
-	}
+
 	var metadata map[string]string
 	if len(opts.Metadata) > 0 {
 		metadata = opts.Metadata
@@ -250,35 +468,126 @@ func (b *bucket) NewTypedWriter(ctx context.Context, key string, contentType str
 		ContentType: contentType,
 		Metadata:    metadata,
 	}
-	return &writer{
-		ctx:   ctx,
-		w:     f,
-		path:  path,
-		attrs: attrs,
-	}, nil
+
+	if b.opts.NoAtomicWrites {
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("open file blob %s: %v", key, err)
+		}
+		if opts.BeforeWrite != nil {
+			if err := opts.BeforeWrite(fileAsFunc(f)); err != nil {
+				return nil, err
+			}
+		}
+		return &writer{ctx: ctx, w: f, path: path, attrs: attrs}, nil
+	}
+
+	suffix, err := newUploadID()
+	if err != nil {
+		return nil, fmt.Errorf("open file blob %s: %v", key, err)
+	}
+	tmpPath := path + ".tmp-" + suffix
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, fmt.Errorf("open file blob %s: %v", key, err)
+	}
+	if opts.BeforeWrite != nil {
+		if err := opts.BeforeWrite(fileAsFunc(f)); err != nil {
+			os.Remove(tmpPath)
+			return nil, err
+		}
+	}
+	// setAttrs(tmpPath, ...) writes tmpPath + attrsExt, so that is the temp
+	// attrs file Close renames into place.
+	return &writer{ctx: ctx, w: f, path: path, tmpPath: tmpPath, tmpAttrsPath: tmpPath + attrsExt, attrs: attrs}, nil
+}
+
+// fileAsFunc builds a driver.As-style callback for BeforeRead/BeforeWrite
+// that exposes the file fileblob has already opened as *os.File, so
+// callers can (for example) flock or Fadvise it before streaming begins.
+func fileAsFunc(f *os.File) func(interface{}) bool {
+	return func(i interface{}) bool {
+		p, ok := i.(**os.File)
+		if !ok {
+			return false
+		}
+		*p = f
+		return true
+	}
 }
 
 type writer struct {
-	ctx   context.Context
-	w     io.WriteCloser
-	path  string
-	attrs xattrs
+	ctx  context.Context
+	w    *os.File
+	path string
+	// tmpPath and tmpAttrsPath are non-empty when publishing atomically
+	// via rename (the default; see Options.NoAtomicWrites).
+	tmpPath      string
+	tmpAttrsPath string
+	attrs        xattrs
 }
 
-func (w writer) Write(p []byte) (n int, err error) {
+func (w *writer) Write(p []byte) (n int, err error) {
 	return w.w.Write(p)
 }
 
-func (w writer) Close() error {
-	// If the write was cancelled, delete the file.
+func (w *writer) Close() error {
+	if w.tmpPath == "" {
+		// NoAtomicWrites: write attrs and close in place, as before.
+		if err := w.ctx.Err(); err != nil {
+			_ = os.Remove(w.path)
+			return err
+		}
+		if err := setAttrs(w.path, w.attrs); err != nil {
+			return fmt.Errorf("write blob attributes: %v", err)
+		}
+		return w.w.Close()
+	}
+
 	if err := w.ctx.Err(); err != nil {
-		_ = os.Remove(w.path)
+		_ = w.w.Close()
+		_ = os.Remove(w.tmpPath)
+		_ = os.Remove(w.tmpAttrsPath)
 		return err
 	}
-	if err := setAttrs(w.path, w.attrs); err != nil {
+	if err := w.w.Sync(); err != nil {
+		_ = w.w.Close()
+		_ = os.Remove(w.tmpPath)
+		return fmt.Errorf("write file blob: %v", err)
+	}
+	if err := w.w.Close(); err != nil {
+		_ = os.Remove(w.tmpPath)
+		return fmt.Errorf("write file blob: %v", err)
+	}
+	if err := setAttrs(w.tmpPath, w.attrs); err != nil {
+		_ = os.Remove(w.tmpPath)
+		_ = os.Remove(w.tmpAttrsPath)
 		return fmt.Errorf("write blob attributes: %v", err)
 	}
-	return w.w.Close()
+	// Publish attrs before content, so a reader that sees the new content
+	// never sees it with stale (or absent) attrs. Between the two renames,
+	// the new attrs can be seen alongside the previous content.
+	if err := os.Rename(w.tmpAttrsPath, w.path+attrsExt); err != nil {
+		_ = os.Remove(w.tmpPath)
+		_ = os.Remove(w.tmpAttrsPath)
+		return fmt.Errorf("write file blob: %v", err)
+	}
+	if err := os.Rename(w.tmpPath, w.path); err != nil {
+		_ = os.Remove(w.tmpPath)
+		return fmt.Errorf("write file blob: %v", err)
+	}
+	return nil
+}
+
+// As implements driver.Writer.As. It supports *os.File, giving access to
+// the (possibly still-temporary) file the writer is streaming into.
+func (w *writer) As(i interface{}) bool {
+	p, ok := i.(**os.File)
+	if !ok {
+		return false
+	}
+	*p = w.w
+	return true
 }
 
 // Delete implements driver.Delete.
@@ -294,7 +603,7 @@ func (b *bucket) Delete(ctx context.Context, key string) error {
 	err = os.Remove(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return fileError{relpath: relpath, msg: err.Error(), kind: driver.NotFound}
+			return fileError{relpath: relpath, msg: err.Error(), kind: driver.NotFound, wrapped: err}
 		}
 		return fmt.Errorf("delete file blob %s: %v", key, err)
 	}
@@ -304,14 +613,66 @@ func (b *bucket) Delete(ctx context.Context, key string) error {
 	return nil
 }
 
+// defaultSignedURLExpiry is used when neither opts.Expiry nor
+// b.opts.DefaultSignedURLExpiry is set.
+const defaultSignedURLExpiry = 1 * time.Hour
+
+// SignedURL implements driver.SignedURL.
 func (b *bucket) SignedURL(ctx context.Context, key string, opts *driver.SignedURLOptions) (string, error) {
-	// TODO(Issue #546): Implemented SignedURL for fileblob.
-	return "", fileError{msg: "SignedURL not supported (see issue #546)", kind: driver.NotImplemented}
+	if len(b.opts.SigningKey) == 0 || b.opts.BaseURL == "" {
+		return "", fileError{relpath: key, msg: "SignedURL requires Options.SigningKey and Options.BaseURL to be set", kind: driver.NotImplemented}
+	}
+	if _, err := resolvePath(key); err != nil {
+		return "", fmt.Errorf("sign file blob %s: %v", key, err)
+	}
+	method := opts.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	expiry := opts.Expiry
+	if expiry == 0 {
+		expiry = b.opts.DefaultSignedURLExpiry
+	}
+	if expiry == 0 {
+		expiry = defaultSignedURLExpiry
+	}
+	expires := time.Now().Add(expiry).Unix()
+	// The signature is computed over the raw key, matching what Handler
+	// recovers from r.URL.Path (which net/http decodes for us); only the
+	// URL itself needs the key's special characters (e.g. a space, which
+	// resolvePath permits) percent-escaped.
+	sig := signature(b.opts.SigningKey, key, method, expires)
+	u := fmt.Sprintf("%s/%s?expires=%d&method=%s&sig=%s", strings.TrimRight(b.opts.BaseURL, "/"), escapeKeyPath(key), expires, method, sig)
+	return u, nil
+}
+
+// escapeKeyPath percent-escapes each slash-separated segment of key so it
+// can be embedded in a URL path, without escaping the slashes themselves.
+func escapeKeyPath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// signature computes the hex-encoded HMAC-SHA256 of key||method||expires
+// under signingKey. It is shared by SignedURL and Handler so that URLs
+// minted by one are verifiable by the other.
+func signature(signingKey []byte, key, method string, expires int64) string {
+	mac := hmac.New(sha256.New, signingKey)
+	io.WriteString(mac, key)
+	io.WriteString(mac, method)
+	io.WriteString(mac, strconv.FormatInt(expires, 10))
+	return hex.EncodeToString(mac.Sum(nil))
 }
 
 type fileError struct {
 	relpath, msg string
 	kind         driver.ErrorKind
+	// wrapped is the underlying *os.PathError, if any; exposed via
+	// (*bucket).ErrorAs.
+	wrapped error
 }
 
 func (e fileError) Error() string {
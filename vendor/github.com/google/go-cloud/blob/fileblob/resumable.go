@@ -0,0 +1,253 @@
+// Copyright 2018 The Go Cloud Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fileblob
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/google/go-cloud/blob/driver"
+)
+
+// uploadsDirName holds in-progress resumable uploads, one subdirectory per
+// upload ID, so a crash mid-upload never leaves a half-written blob at its
+// final key.
+const uploadsDirName = ".uploads"
+
+// uploadManifest is persisted as manifest.json inside an upload's directory
+// so that ResumeWrite can pick up where a previous process left off.
+type uploadManifest struct {
+	Key         string            `json:"key"`
+	ContentType string            `json:"contentType"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+	Committed   int64             `json:"committed"`
+	NumParts    int               `json:"numParts"`
+}
+
+// NewResumableWriter starts a new chunked upload of key under a freshly
+// generated ID (ResumeWriter reopens an existing one), buffering writes
+// into numbered part files under <b.dir>/.uploads/<id> until Commit
+// publishes them to key.
+func (b *bucket) NewResumableWriter(ctx context.Context, key, contentType string, opts *driver.WriterOptions) (driver.ResumableWriter, error) {
+	if _, err := resolvePath(key); err != nil {
+		return nil, fmt.Errorf("open file blob %s: %v", key, err)
+	}
+	id, err := newUploadID()
+	if err != nil {
+		return nil, fmt.Errorf("start resumable upload %s: %v", key, err)
+	}
+	var metadata map[string]string
+	if opts != nil && len(opts.Metadata) > 0 {
+		metadata = opts.Metadata
+	}
+	w := &resumableWriter{
+		b:   b,
+		ctx: ctx,
+		id:  id,
+		dir: filepath.Join(b.dir, uploadsDirName, id),
+		manifest: uploadManifest{
+			Key:         key,
+			ContentType: contentType,
+			Metadata:    metadata,
+		},
+	}
+	if err := os.MkdirAll(w.dir, 0777); err != nil {
+		return nil, fmt.Errorf("start resumable upload %s: %v", key, err)
+	}
+	if err := w.writeManifest(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// ResumeWriter implements driver.ResumableBucket, reopening an upload
+// previously started by NewResumableWriter so writing can continue after
+// id's committed offset.
+func (b *bucket) ResumeWriter(ctx context.Context, key, id string) (driver.ResumableWriter, error) {
+	dir := filepath.Join(b.dir, uploadsDirName, id)
+	data, err := ioutil.ReadFile(filepath.Join(dir, "manifest.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fileError{relpath: id, msg: "no such upload", kind: driver.NotFound}
+		}
+		return nil, fmt.Errorf("resume upload %s: %v", id, err)
+	}
+	var manifest uploadManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("resume upload %s: %v", id, err)
+	}
+	if manifest.Key != key {
+		return nil, fmt.Errorf("resume upload %s: was started for key %q, not %q", id, manifest.Key, key)
+	}
+	return &resumableWriter{b: b, ctx: ctx, id: id, dir: dir, manifest: manifest}, nil
+}
+
+func newUploadID() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
+
+// resumableWriter implements driver.ResumableWriter: each Write is
+// buffered into its own numbered part file, fsynced and renamed into
+// place before the manifest's committed offset is advanced, so a crash
+// can never lose or corrupt a part that's already been acknowledged.
+type resumableWriter struct {
+	b        *bucket
+	ctx      context.Context
+	id       string
+	dir      string
+	manifest uploadManifest
+}
+
+// ID implements driver.ResumableWriter.
+func (w *resumableWriter) ID() string { return w.id }
+
+// Size implements driver.ResumableWriter, returning the durable
+// (fsynced and renamed) offset written so far.
+func (w *resumableWriter) Size() int64 { return w.manifest.Committed }
+
+// Write implements driver.ResumableWriter. It writes p to a new part
+// file, which is fsynced and renamed into its final, numbered name only
+// after its contents are durable; the manifest is then updated to
+// reflect the new committed offset.
+func (w *resumableWriter) Write(p []byte) (int, error) {
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	partPath := filepath.Join(w.dir, fmt.Sprintf("%06d", w.manifest.NumParts))
+	tmpPath := partPath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, fmt.Errorf("write upload %s: %v", w.id, err)
+	}
+	n, err := f.Write(p)
+	if err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return n, fmt.Errorf("write upload %s: %v", w.id, err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return n, fmt.Errorf("write upload %s: %v", w.id, err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return n, fmt.Errorf("write upload %s: %v", w.id, err)
+	}
+	if err := os.Rename(tmpPath, partPath); err != nil {
+		os.Remove(tmpPath)
+		return n, fmt.Errorf("write upload %s: %v", w.id, err)
+	}
+	w.manifest.NumParts++
+	w.manifest.Committed += int64(n)
+	if err := w.writeManifest(); err != nil {
+		return n, err
+	}
+	return n, nil
+}
+
+// Commit implements driver.ResumableWriter: it concatenates every part,
+// in order, into a temp file beside key's path, writes attrs, and atomically
+// renames the result (and its attrs) into place before removing the
+// upload directory.
+func (w *resumableWriter) Commit() error {
+	relpath, err := resolvePath(w.manifest.Key)
+	if err != nil {
+		return fmt.Errorf("commit upload %s: %v", w.id, err)
+	}
+	finalPath := filepath.Join(w.b.dir, relpath)
+	if err := os.MkdirAll(filepath.Dir(finalPath), 0777); err != nil {
+		return fmt.Errorf("commit upload %s: %v", w.id, err)
+	}
+	tmpPath := finalPath + ".tmp-" + w.id
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return fmt.Errorf("commit upload %s: %v", w.id, err)
+	}
+	for i := 0; i < w.manifest.NumParts; i++ {
+		part, err := os.Open(filepath.Join(w.dir, fmt.Sprintf("%06d", i)))
+		if err != nil {
+			out.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("commit upload %s: %v", w.id, err)
+		}
+		_, err = io.Copy(out, part)
+		part.Close()
+		if err != nil {
+			out.Close()
+			os.Remove(tmpPath)
+			return fmt.Errorf("commit upload %s: %v", w.id, err)
+		}
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return fmt.Errorf("commit upload %s: %v", w.id, err)
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("commit upload %s: %v", w.id, err)
+	}
+	if err := setAttrs(tmpPath, xattrs{ContentType: w.manifest.ContentType, Metadata: w.manifest.Metadata}); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("commit upload %s: %v", w.id, err)
+	}
+	// setAttrs wrote tmpPath + attrsExt; publish it before the content.
+	if err := os.Rename(tmpPath+attrsExt, finalPath+attrsExt); err != nil {
+		os.Remove(tmpPath)
+		os.Remove(tmpPath + attrsExt)
+		return fmt.Errorf("commit upload %s: %v", w.id, err)
+	}
+	if err := os.Rename(tmpPath, finalPath); err != nil {
+		return fmt.Errorf("commit upload %s: %v", w.id, err)
+	}
+	return os.RemoveAll(w.dir)
+}
+
+// Cancel implements driver.ResumableWriter by discarding all written
+// parts and the manifest.
+func (w *resumableWriter) Cancel() error {
+	return os.RemoveAll(w.dir)
+}
+
+func (w *resumableWriter) writeManifest() error {
+	data, err := json.Marshal(w.manifest)
+	if err != nil {
+		return fmt.Errorf("write upload %s manifest: %v", w.id, err)
+	}
+	path := filepath.Join(w.dir, "manifest.json")
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0666); err != nil {
+		return fmt.Errorf("write upload %s manifest: %v", w.id, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("write upload %s manifest: %v", w.id, err)
+	}
+	return nil
+}
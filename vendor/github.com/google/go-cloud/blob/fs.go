@@ -0,0 +1,245 @@
+// Copyright 2018 The Go Cloud Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blob
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// Bucket implements io/fs.FS, io/fs.ReadDirFS, io/fs.StatFS, and
+// io/fs.SubFS so that a *Bucket can be handed to anything that accepts an
+// fs.FS: http.FS, template.ParseFS, fs.WalkDir, and so on.
+//
+// Because those interfaces take no context.Context, Open, ReadDir, and Stat
+// obtain one (along with a *ReaderOptions) from the callback registered via
+// SetIOFSCallback. If none has been set, they use context.Background() and
+// nil options.
+var (
+	_ fs.FS        = (*Bucket)(nil)
+	_ fs.ReadDirFS = (*Bucket)(nil)
+	_ fs.StatFS    = (*Bucket)(nil)
+	_ fs.SubFS     = (*Bucket)(nil)
+)
+
+// SetIOFSCallback registers f as the source of the context.Context and
+// *ReaderOptions used by the io/fs.FS methods Open, ReadDir, and Stat (and
+// so by the fs.FS that Sub returns; Sub itself does not call f). f is
+// called once per Open, ReadDir, or Stat call that reaches the bucket
+// (Open(".") calls it twice, as it also lists the root via ReadDir); it
+// may return a fresh context each time, e.g. one with a per-call timeout.
+// Passing nil restores the default of context.Background() and nil
+// options.
+func (b *Bucket) SetIOFSCallback(f func() (context.Context, *ReaderOptions)) {
+	b.ioFSCallback = f
+}
+
+func (b *Bucket) ioFSContext() (context.Context, *ReaderOptions) {
+	if b.ioFSCallback == nil {
+		return context.Background(), nil
+	}
+	return b.ioFSCallback()
+}
+
+// Open implements io/fs.FS.
+func (b *Bucket) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	ctx, opts := b.ioFSContext()
+	if name == "." {
+		return b.openRootDir(ctx)
+	}
+	r, err := b.NewReader(ctx, name, opts)
+	if err != nil {
+		if IsNotExist(err) {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &blobFile{r: r, name: name}, nil
+}
+
+// ReadDir implements io/fs.ReadDirFS. It lists name as a "directory" by
+// listing with a "/" delimiter, via a recursive, delimiter-aware
+// ListPaged (see fileblob's implementation for the reference driver).
+func (b *Bucket) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	ctx, _ := b.ioFSContext()
+	prefix := ""
+	if name != "." {
+		prefix = name + "/"
+	}
+	var entries []fs.DirEntry
+	iter := b.List(&ListOptions{Prefix: prefix, Delimiter: "/"})
+	for {
+		obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+		}
+		entries = append(entries, &blobDirEntry{obj: obj, name: path.Base(strings.TrimSuffix(obj.Key, "/"))})
+	}
+	return entries, nil
+}
+
+// Stat implements io/fs.StatFS.
+func (b *Bucket) Stat(name string) (fs.FileInfo, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		// The root has no backing object to call Attributes on; fs.WalkDir
+		// stats it before ever reading a directory, so it needs a synthetic
+		// directory FileInfo, the same as Open(".") returns.
+		return &blobFileInfo{name: ".", isDir: true}, nil
+	}
+	ctx, _ := b.ioFSContext()
+	attrs, err := b.Attributes(ctx, name)
+	if err != nil {
+		if IsNotExist(err) {
+			return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+		}
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err}
+	}
+	return &blobFileInfo{name: path.Base(name), size: attrs.Size, modTime: attrs.ModTime}, nil
+}
+
+// Sub implements io/fs.SubFS by returning a view of b rooted at dir; it
+// shares the same driver.Bucket and IOFSCallback as b.
+func (b *Bucket) Sub(dir string) (fs.FS, error) {
+	if !fs.ValidPath(dir) {
+		return nil, &fs.PathError{Op: "sub", Path: dir, Err: fs.ErrInvalid}
+	}
+	if dir == "." {
+		return b, nil
+	}
+	return &subFS{b: b, dir: dir}, nil
+}
+
+func (b *Bucket) openRootDir(ctx context.Context) (fs.File, error) {
+	entries, err := b.ReadDir(".")
+	if err != nil {
+		return nil, err
+	}
+	return &blobDir{name: ".", entries: entries}, nil
+}
+
+// subFS implements fs.FS, fs.ReadDirFS, and fs.StatFS for Sub by
+// prefixing every name with dir before delegating to the parent Bucket.
+type subFS struct {
+	b   *Bucket
+	dir string
+}
+
+func (s *subFS) Open(name string) (fs.File, error)         { return s.b.Open(s.join(name)) }
+func (s *subFS) ReadDir(name string) ([]fs.DirEntry, error) { return s.b.ReadDir(s.join(name)) }
+func (s *subFS) Stat(name string) (fs.FileInfo, error)      { return s.b.Stat(s.join(name)) }
+func (s *subFS) join(name string) string {
+	if name == "." {
+		return s.dir
+	}
+	return s.dir + "/" + name
+}
+
+// blobFile adapts a *Reader to fs.File.
+type blobFile struct {
+	r    *Reader
+	name string
+}
+
+func (f *blobFile) Stat() (fs.FileInfo, error) {
+	attrs := f.r.Attributes()
+	return &blobFileInfo{name: path.Base(f.name), size: attrs.Size, modTime: attrs.ModTime}, nil
+}
+func (f *blobFile) Read(p []byte) (int, error) { return f.r.Read(p) }
+func (f *blobFile) Close() error               { return f.r.Close() }
+
+// blobDir implements fs.File (and fs.ReadDirFile) for the synthetic root
+// directory returned by Open(".").
+type blobDir struct {
+	name    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *blobDir) Stat() (fs.FileInfo, error) {
+	return &blobFileInfo{name: d.name, isDir: true}, nil
+}
+func (d *blobDir) Read([]byte) (int, error) { return 0, &fs.PathError{Op: "read", Path: d.name, Err: fs.ErrInvalid} }
+func (d *blobDir) Close() error              { return nil }
+func (d *blobDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		rest := d.entries[d.offset:]
+		d.offset = len(d.entries)
+		return rest, nil
+	}
+	if d.offset >= len(d.entries) {
+		return nil, io.EOF
+	}
+	end := d.offset + n
+	if end > len(d.entries) {
+		end = len(d.entries)
+	}
+	rest := d.entries[d.offset:end]
+	d.offset = end
+	return rest, nil
+}
+
+// blobDirEntry adapts a *ListObject to fs.DirEntry.
+type blobDirEntry struct {
+	obj  *ListObject
+	name string
+}
+
+func (e *blobDirEntry) Name() string { return e.name }
+func (e *blobDirEntry) IsDir() bool  { return e.obj.IsDir }
+func (e *blobDirEntry) Type() fs.FileMode {
+	if e.obj.IsDir {
+		return fs.ModeDir
+	}
+	return 0
+}
+func (e *blobDirEntry) Info() (fs.FileInfo, error) {
+	return &blobFileInfo{name: e.name, size: e.obj.Size, modTime: e.obj.ModTime, isDir: e.obj.IsDir}, nil
+}
+
+// blobFileInfo adapts blob Attributes/ListObject fields to fs.FileInfo.
+type blobFileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func (fi *blobFileInfo) Name() string { return fi.name }
+func (fi *blobFileInfo) Size() int64  { return fi.size }
+func (fi *blobFileInfo) Mode() fs.FileMode {
+	if fi.isDir {
+		return fs.ModeDir
+	}
+	return 0
+}
+func (fi *blobFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *blobFileInfo) IsDir() bool         { return fi.isDir }
+func (fi *blobFileInfo) Sys() interface{}    { return nil }
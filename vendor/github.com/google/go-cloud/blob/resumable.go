@@ -0,0 +1,86 @@
+// Copyright 2018 The Go Cloud Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blob
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/go-cloud/blob/driver"
+)
+
+// ResumableWriter is a handle to a chunked upload that can survive the
+// writing process being interrupted: its ID can be persisted and passed
+// to (*Bucket).ResumeWrite to continue writing after a crash or restart.
+// Unlike Writer, advancing the upload past a given point is explicit
+// (Write only returns once its bytes are durable), and publishing the
+// result requires an explicit Commit.
+type ResumableWriter struct {
+	w driver.ResumableWriter
+}
+
+// ID identifies this upload; pass it to ResumeWrite to continue writing
+// to it from another process or after a restart.
+func (w *ResumableWriter) ID() string { return w.w.ID() }
+
+// Size returns the number of bytes durably written so far.
+func (w *ResumableWriter) Size() int64 { return w.w.Size() }
+
+// Write buffers p into the upload, returning only once it is durable.
+func (w *ResumableWriter) Write(p []byte) (int, error) { return w.w.Write(p) }
+
+// Commit publishes everything written so far to the upload's key.
+func (w *ResumableWriter) Commit() error { return w.w.Commit() }
+
+// Cancel discards the upload and everything written to it.
+func (w *ResumableWriter) Cancel() error { return w.w.Cancel() }
+
+// NewResumableWriter starts a new chunked, resumable upload to key. The
+// underlying driver must implement driver.ResumableBucket, as fileblob's
+// part-file-backed uploads do.
+func (b *Bucket) NewResumableWriter(ctx context.Context, key string, opts *WriterOptions) (*ResumableWriter, error) {
+	rb, ok := b.b.(driver.ResumableBucket)
+	if !ok {
+		return nil, fmt.Errorf("blob: %T does not implement driver.ResumableBucket", b.b)
+	}
+	var contentType string
+	var dopts driver.WriterOptions
+	if opts != nil {
+		contentType = opts.ContentType
+		dopts.Metadata = opts.Metadata
+		dopts.BeforeWrite = opts.BeforeWrite
+	}
+	w, err := rb.NewResumableWriter(ctx, key, contentType, &dopts)
+	if err != nil {
+		return nil, err
+	}
+	return &ResumableWriter{w: w}, nil
+}
+
+// ResumeWrite reopens a chunked upload previously started by
+// NewResumableWriter, returning a ResumableWriter positioned at the
+// upload's last durable (committed) offset. id is the value returned by
+// (*ResumableWriter).ID() for the original upload.
+func (b *Bucket) ResumeWrite(ctx context.Context, key, id string) (*ResumableWriter, error) {
+	rb, ok := b.b.(driver.ResumableBucket)
+	if !ok {
+		return nil, fmt.Errorf("blob: %T does not implement driver.ResumableBucket", b.b)
+	}
+	w, err := rb.ResumeWriter(ctx, key, id)
+	if err != nil {
+		return nil, err
+	}
+	return &ResumableWriter{w: w}, nil
+}